@@ -1,72 +1,54 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"ikago/internal/config"
 	"ikago/internal/crypto"
 	"ikago/internal/log"
+	"ikago/internal/metrics"
 	"ikago/internal/pcap"
 	"math/rand"
 	"net"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-var argListDevs = flag.Bool("list-devices", false, "List all valid pcap devices in current computer.")
-var argConfig = flag.String("c", "", "Configuration file.")
-var argListenDevs = flag.String("listen-devices", "", "pcap devices for listening.")
-var argUpDev = flag.String("upstream-device", "", "pcap device for routing upstream to.")
-var argMethod = flag.String("method", "plain", "Method of encryption.")
-var argPassword = flag.String("password", "", "Password of the encryption.")
-var argVerbose = flag.Bool("v", false, "Print verbose messages.")
-var argUpPort = flag.Int("upstream-port", 0, "Port for routing upstream.")
-var argFilters = flag.String("f", "", "Filters.")
-var argServer = flag.String("s", "", "Server.")
-
-func init() {
-	// Parse arguments
-	flag.Parse()
-}
+var (
+	loader = config.NewLoader("ikago-client")
+	opts   = config.Register(loader)
+)
+
+var argListDevs = loader.Bool("list-devices", false, "List all valid pcap devices in current computer.")
 
 func main() {
 	var (
 		err        error
 		cfg        *config.Config
 		filters    = make([]pcap.Filter, 0)
-		serverIP   net.IP
-		serverPort uint16
+		upstreams  = make([]*net.UDPAddr, 0)
 		listenDevs = make([]*pcap.Device, 0)
 		upDev      *pcap.Device
 		gatewayDev *pcap.Device
 		c          crypto.Crypto
 	)
 
-	// Configuration
-	if *argConfig != "" {
-		cfg, err = config.ParseFile(*argConfig)
-		if err != nil {
-			log.Fatalln(fmt.Errorf("parse config file %s: %w", *argConfig, err))
-		}
-	} else {
-		cfg = &config.Config{
-			ListenDevs: splitArg(*argListenDevs),
-			UpDev:      *argUpDev,
-			Method:     *argMethod,
-			Password:   *argPassword,
-			Verbose:    *argVerbose,
-			UpPort:     *argUpPort,
-			Filters:    splitArg(*argFilters),
-			Server:     *argServer,
-		}
+	// Configuration: CLI flags > IKAGO_* env vars > -c config file > defaults
+	if err := loader.Load(os.Args[1:], "c"); err != nil {
+		log.Fatalln(fmt.Errorf("load configuration: %w", err))
 	}
+	cfg = opts.Config()
 
 	// Log
 	log.SetVerbose(cfg.Verbose)
+	if cfg.LogFormat == "json" {
+		log.SetFormat(log.FormatJSON)
+	}
 
 	// Exclusive commands
 	if *argListDevs {
@@ -85,7 +67,7 @@ func main() {
 	if len(cfg.Filters) <= 0 {
 		log.Fatalln("Please provide filters by -f [filters].")
 	}
-	if cfg.Server == "" {
+	if len(cfg.Servers) <= 0 {
 		log.Fatalln("Please provide server by -s [address:port].")
 	}
 	for _, strFilter := range cfg.Filters {
@@ -109,12 +91,16 @@ func main() {
 			var exist bool
 			for _, filter := range filters {
 				switch filter.FilterType() {
-				case pcap.FilterTypeIP, pcap.FilterTypeIPPort:
+				case pcap.FilterTypeIP, pcap.FilterTypeIPPort, pcap.FilterTypeCIDR:
 					break
 				case pcap.FilterTypePort:
 					if filter.(*pcap.PortFilter).Port == uint16(cfg.UpPort) {
 						exist = true
 					}
+				case pcap.FilterTypeCIDRPort:
+					if filter.(*pcap.CIDRPortFilter).Port == uint16(cfg.UpPort) {
+						exist = true
+					}
 				default:
 					log.Fatalln(fmt.Errorf("parse filter %s: %w", filter, fmt.Errorf("type %d not support", filter.FilterType())))
 				}
@@ -127,24 +113,25 @@ func main() {
 			}
 		}
 	}
-	serverIPPort, err := pcap.ParseIPPort(cfg.Server)
-	if err != nil {
-		log.Fatalln(fmt.Errorf("parse server %s: %w", cfg.Server, err))
+	for _, strServer := range cfg.Servers {
+		serverIPPort, err := pcap.ParseIPPort(strServer)
+		if err != nil {
+			log.Fatalln(fmt.Errorf("parse server %s: %w", strServer, err))
+		}
+		upstreams = append(upstreams, &net.UDPAddr{IP: serverIPPort.IP, Port: int(serverIPPort.Port)})
 	}
-	serverIP = serverIPPort.IP
-	serverPort = serverIPPort.Port
 	c, err = crypto.Parse(cfg.Method, cfg.Password)
 	if err != nil {
 		log.Fatalln(fmt.Errorf("parse crypto: %w", err))
 	}
 	if len(filters) == 1 {
-		log.Infof("Proxy from %s through :%d to %s\n", filters[0], cfg.UpPort, serverIPPort)
+		log.Infof("Proxy from %s through :%d to %v (%s)\n", filters[0], cfg.UpPort, upstreams, cfg.Policy)
 	} else {
 		log.Info("Proxy:")
 		for _, filter := range filters {
 			log.Infof("\n  %s", filter)
 		}
-		log.Infof(" through :%d to %s\n", cfg.UpPort, serverIPPort)
+		log.Infof(" through :%d to %v (%s)\n", cfg.UpPort, upstreams, cfg.Policy)
 	}
 
 	// Find devices
@@ -173,14 +160,28 @@ func main() {
 	p := pcap.Client{
 		Filters:    filters,
 		UpPort:     uint16(cfg.UpPort),
-		ServerIP:   serverIP,
-		ServerPort: serverPort,
+		Upstreams:  upstreams,
+		Policy:     pcap.Policy(cfg.Policy),
 		ListenDevs: listenDevs,
 		UpDev:      upDev,
 		GatewayDev: gatewayDev,
+		Method:     cfg.Method,
+		Password:   cfg.Password,
 		Crypto:     c,
 	}
 
+	// Metrics and pprof
+	if cfg.MetricsAddr != "" {
+		m := metrics.New(prometheus.DefaultRegisterer)
+		p.Metrics = m
+		go func() {
+			if err := m.ListenAndServe(cfg.MetricsAddr); err != nil {
+				log.Infof("Metrics: %s\n", err)
+			}
+		}()
+		defer m.Shutdown(context.Background())
+	}
+
 	// Wait signals
 	sig := make(chan os.Signal)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
@@ -190,24 +191,36 @@ func main() {
 		os.Exit(0)
 	}()
 
-	err = p.Open()
-	if err != nil {
-		log.Fatalln(fmt.Errorf("open pcap: %w", err))
-	}
-}
+	// Hot reload: re-resolve CLI flags, IKAGO_* env vars and the -c file
+	// with the same precedence used at startup.
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			log.Infoln("Reloading configuration")
 
-func splitArg(s string) []string {
-	if s == "" {
-		return nil
-	} else {
-		result := make([]string, 0)
+			if err := loader.Load(os.Args[1:], "c"); err != nil {
+				log.Infof("Reload: %s\n", err)
+				continue
+			}
 
-		strs := strings.Split(s, ",")
+			newCfg := opts.Config()
+			if err := p.Reload(newCfg); err != nil {
+				log.Infof("Reload: %s\n", err)
+				continue
+			}
+			if newCfg.LogFormat == "json" {
+				log.SetFormat(log.FormatJSON)
+			} else {
+				log.SetFormat(log.FormatText)
+			}
 
-		for _, str := range strs {
-			result = append(result, strings.Trim(str, " "))
+			log.Infoln("Reload: configuration applied")
 		}
+	}()
 
-		return result
+	err = p.Open()
+	if err != nil {
+		log.Fatalln(fmt.Errorf("open pcap: %w", err))
 	}
 }
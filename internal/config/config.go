@@ -0,0 +1,106 @@
+// Package config describes the configuration of the ikago client and the
+// schema used to resolve it from CLI flags, an INI or JSON config file,
+// and IKAGO_* environment variables.
+package config
+
+import (
+	"strings"
+)
+
+// Config describes the configuration of the ikago client.
+type Config struct {
+	// ListenDevs is the pcap devices for listening.
+	ListenDevs []string
+	// UpDev is the pcap device for routing upstream to.
+	UpDev string
+	// Method is the method of encryption.
+	Method string
+	// Password is the password of the encryption.
+	Password string
+	// Verbose describes whether to print verbose messages.
+	Verbose bool
+	// UpPort is the port for routing upstream.
+	UpPort int
+	// Filters is the filters.
+	Filters []string
+	// Servers is the list of upstream servers, as "host:port" pairs.
+	Servers []string
+	// Policy is the policy used to select an upstream among Servers:
+	// "round-robin", "random", "hash" or "failover".
+	Policy string
+	// LogFormat is the rendering used for log output: "text" or "json".
+	LogFormat string
+	// MetricsAddr is the address the Prometheus metrics and pprof HTTP
+	// server listens on, e.g. ":6060". Disabled when empty.
+	MetricsAddr string
+}
+
+// Options binds every configuration option to a Loader, so the client's
+// schema is declared once and shared by flag registration, file parsing
+// and environment lookup.
+type Options struct {
+	ConfigFile  *string
+	ListenDevs  *string
+	UpDev       *string
+	Method      *string
+	Password    *string
+	Verbose     *bool
+	UpPort      *int
+	Filters     *string
+	Servers     *string
+	Policy      *string
+	LogFormat   *string
+	MetricsAddr *string
+}
+
+// Register declares the client's options on l and returns the pointers
+// Loader.Load will fill.
+func Register(l *Loader) *Options {
+	return &Options{
+		ConfigFile:  l.String("c", "", "Configuration file (JSON or INI)."),
+		ListenDevs:  l.String("listen-devices", "", "pcap devices for listening."),
+		UpDev:       l.String("upstream-device", "", "pcap device for routing upstream to."),
+		Method:      l.String("method", "plain", "Method of encryption."),
+		Password:    l.String("password", "", "Password of the encryption."),
+		Verbose:     l.Bool("v", false, "Print verbose messages."),
+		UpPort:      l.Int("upstream-port", 0, "Port for routing upstream."),
+		Filters:     l.String("f", "", "Filters."),
+		Servers:     l.String("s", "", "Servers, separated by comma."),
+		Policy:      l.String("policy", "round-robin", "Policy of upstream selection: round-robin, random, hash or failover."),
+		LogFormat:   l.String("log-format", "text", "Log format: text or json."),
+		MetricsAddr: l.String("metrics-addr", "", "Address to serve Prometheus metrics and pprof on, e.g. :6060. Disabled if empty."),
+	}
+}
+
+// Config builds a Config from the resolved option values.
+func (o *Options) Config() *Config {
+	return &Config{
+		ListenDevs:  splitList(*o.ListenDevs),
+		UpDev:       *o.UpDev,
+		Method:      *o.Method,
+		Password:    *o.Password,
+		Verbose:     *o.Verbose,
+		UpPort:      *o.UpPort,
+		Filters:     splitList(*o.Filters),
+		Servers:     splitList(*o.Servers),
+		Policy:      *o.Policy,
+		LogFormat:   *o.LogFormat,
+		MetricsAddr: *o.MetricsAddr,
+	}
+}
+
+// splitList splits a comma-separated option value, trimming whitespace
+// around each item.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	parts := strings.Split(s, ",")
+	result := make([]string, 0, len(parts))
+	for _, p := range parts {
+		result = append(result, strings.TrimSpace(p))
+	}
+
+	return result
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// parseFile reads a config file and flattens it into option name -> string
+// value pairs. JSON (".json" or no recognised extension) and INI (".ini")
+// are supported; option names are looked up verbatim as JSON object keys
+// or INI keys, ignoring any INI section.
+func parseFile(path string) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ini":
+		return parseINIFile(path)
+	default:
+		return parseJSONFile(path)
+	}
+}
+
+func parseJSONFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer file.Close()
+
+	var raw map[string]interface{}
+	if err := json.NewDecoder(file).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		values[k] = stringify(v)
+	}
+
+	return values, nil
+}
+
+// stringify renders a decoded JSON value the way Loader options expect it:
+// scalars as-is and arrays as a comma-separated list, matching the CLI's
+// own comma-separated convention for multi-value flags.
+func stringify(v interface{}) string {
+	switch t := v.(type) {
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for _, e := range t {
+			parts = append(parts, stringify(e))
+		}
+		return strings.Join(parts, ",")
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	case float64:
+		if t == float64(int(t)) {
+			return fmt.Sprintf("%d", int(t))
+		}
+		return fmt.Sprintf("%v", t)
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+// parseINIFile parses a minimal INI file: "key = value" or "key: value"
+// pairs, "#" or ";" comments, and "[section]" headers, which are accepted
+// for readability but not reflected in option names.
+func parseINIFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+
+		key := strings.TrimSpace(line[:sep])
+		value := strings.TrimSpace(line[sep+1:])
+		value = strings.Trim(value, `"`)
+
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+
+	return values, nil
+}
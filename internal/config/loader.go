@@ -0,0 +1,185 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// kind describes the type of value an option holds.
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+)
+
+// option describes a single configuration option, registered once and
+// shared by flag parsing, file parsing and environment variable lookup.
+type option struct {
+	name  string
+	kind  kind
+	usage string
+	value interface{} // *string, *bool or *int, also bound to the flag.FlagSet
+}
+
+// Loader registers configuration options once and resolves their values
+// from CLI flags, an IKAGO_* environment variable, a config file (JSON or
+// INI) and a default, in that order of precedence. It keeps -list-devices
+// and any future subcommand from having to duplicate this parsing.
+type Loader struct {
+	fs   *flag.FlagSet
+	opts []*option
+}
+
+// NewLoader creates a Loader whose usage is printed under the given name.
+func NewLoader(name string) *Loader {
+	return &Loader{fs: flag.NewFlagSet(name, flag.ExitOnError)}
+}
+
+// String registers a string option and returns the pointer Load will fill.
+func (l *Loader) String(name, def, usage string) *string {
+	p := l.fs.String(name, def, usage)
+	l.opts = append(l.opts, &option{name: name, kind: kindString, usage: usage, value: p})
+	return p
+}
+
+// Bool registers a bool option and returns the pointer Load will fill.
+func (l *Loader) Bool(name string, def bool, usage string) *bool {
+	p := l.fs.Bool(name, def, usage)
+	l.opts = append(l.opts, &option{name: name, kind: kindBool, usage: usage, value: p})
+	return p
+}
+
+// Int registers an int option and returns the pointer Load will fill.
+func (l *Loader) Int(name string, def int, usage string) *int {
+	p := l.fs.Int(name, def, usage)
+	l.opts = append(l.opts, &option{name: name, kind: kindInt, usage: usage, value: p})
+	return p
+}
+
+// Load parses args and resolves every registered option with precedence
+// CLI flag > IKAGO_* environment variable > configFlagName's file > default.
+// configFlagName is the name of a previously registered string option that
+// points to the config file; it is itself resolved from CLI flags and the
+// environment only, since it would otherwise have to appear inside the
+// file it names.
+func (l *Loader) Load(args []string, configFlagName string) error {
+	if err := l.fs.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+
+	explicit := make(map[string]bool, l.fs.NFlag())
+	l.fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	var fileValues map[string]string
+
+	for _, o := range l.opts {
+		if o.name == configFlagName {
+			continue
+		}
+
+		if explicit[o.name] {
+			continue
+		}
+
+		// Start from the default, so that an option dropped from the
+		// environment or the file since a previous Load falls back to it.
+		if f := l.fs.Lookup(o.name); f != nil {
+			if err := o.set(f.DefValue); err != nil {
+				return fmt.Errorf("default %s: %w", o.name, err)
+			}
+		}
+
+		if v, ok := os.LookupEnv(envName(o.name)); ok {
+			if err := o.set(v); err != nil {
+				return fmt.Errorf("env %s: %w", envName(o.name), err)
+			}
+			continue
+		}
+
+		if fileValues == nil {
+			var err error
+			fileValues, err = l.loadFile(configFlagName, explicit)
+			if err != nil {
+				return err
+			}
+			if fileValues == nil {
+				fileValues = map[string]string{}
+			}
+		}
+
+		if v, ok := fileValues[o.name]; ok {
+			if err := o.set(v); err != nil {
+				return fmt.Errorf("config file: option %s: %w", o.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadFile resolves the path of the config file option (CLI then env, it
+// is never read from the file itself) and parses it, returning nil with no
+// error if no file was configured.
+func (l *Loader) loadFile(configFlagName string, explicit map[string]bool) (map[string]string, error) {
+	path := ""
+
+	for _, o := range l.opts {
+		if o.name != configFlagName {
+			continue
+		}
+
+		if s, ok := o.value.(*string); ok {
+			path = *s
+		}
+
+		if !explicit[o.name] {
+			if v, ok := os.LookupEnv(envName(o.name)); ok {
+				path = v
+			}
+		}
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	values, err := parseFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+func (o *option) set(s string) error {
+	switch o.kind {
+	case kindString:
+		*o.value.(*string) = s
+	case kindBool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool %s: %w", s, err)
+		}
+		*o.value.(*bool) = b
+	case kindInt:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid int %s: %w", s, err)
+		}
+		*o.value.(*int) = i
+	}
+
+	return nil
+}
+
+// envName returns the IKAGO_* environment variable name for an option.
+func envName(name string) string {
+	return "IKAGO_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("write config file: %s", err)
+	}
+
+	return path
+}
+
+func TestLoaderCLIOverridesEnvAndFile(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{"greeting": "from-file"}`)
+
+	os.Setenv("IKAGO_GREETING", "from-env")
+	defer os.Unsetenv("IKAGO_GREETING")
+
+	l := NewLoader("test")
+	l.String("c", "", "config file")
+	greeting := l.String("greeting", "from-default", "greeting")
+
+	if err := l.Load([]string{"-c", path, "-greeting", "from-cli"}, "c"); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	if *greeting != "from-cli" {
+		t.Fatalf("greeting = %q, want %q", *greeting, "from-cli")
+	}
+}
+
+func TestLoaderEnvOverridesFileAndDefault(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{"greeting": "from-file"}`)
+
+	os.Setenv("IKAGO_GREETING", "from-env")
+	defer os.Unsetenv("IKAGO_GREETING")
+
+	l := NewLoader("test")
+	l.String("c", "", "config file")
+	greeting := l.String("greeting", "from-default", "greeting")
+
+	if err := l.Load([]string{"-c", path}, "c"); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	if *greeting != "from-env" {
+		t.Fatalf("greeting = %q, want %q", *greeting, "from-env")
+	}
+}
+
+func TestLoaderFileOverridesDefault(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{"greeting": "from-file"}`)
+
+	l := NewLoader("test")
+	l.String("c", "", "config file")
+	greeting := l.String("greeting", "from-default", "greeting")
+
+	if err := l.Load([]string{"-c", path}, "c"); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	if *greeting != "from-file" {
+		t.Fatalf("greeting = %q, want %q", *greeting, "from-file")
+	}
+}
+
+func TestLoaderFallsBackToDefaultWithNoFileOrEnv(t *testing.T) {
+	l := NewLoader("test")
+	l.String("c", "", "config file")
+	greeting := l.String("greeting", "from-default", "greeting")
+
+	if err := l.Load(nil, "c"); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	if *greeting != "from-default" {
+		t.Fatalf("greeting = %q, want %q", *greeting, "from-default")
+	}
+}
+
+func TestLoaderReadsINIFile(t *testing.T) {
+	path := writeConfigFile(t, "config.ini", "greeting = from-ini\n")
+
+	l := NewLoader("test")
+	l.String("c", "", "config file")
+	greeting := l.String("greeting", "from-default", "greeting")
+
+	if err := l.Load([]string{"-c", path}, "c"); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	if *greeting != "from-ini" {
+		t.Fatalf("greeting = %q, want %q", *greeting, "from-ini")
+	}
+}
@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// aesCrypto is a Crypto which encrypts and decrypts contents by AES in CFB
+// mode.
+type aesCrypto struct {
+	keySize int
+	key     []byte
+}
+
+func newAES(method, password string) (*aesCrypto, error) {
+	if password == "" {
+		return nil, fmt.Errorf("password not provided")
+	}
+
+	var keySize int
+	switch method {
+	case "aes-128-cfb":
+		keySize = 16
+	case "aes-192-cfb":
+		keySize = 24
+	case "aes-256-cfb":
+		keySize = 32
+	default:
+		return nil, fmt.Errorf("method %s not support", method)
+	}
+
+	return &aesCrypto{
+		keySize: keySize,
+		key:     kdf(password, keySize),
+	}, nil
+}
+
+// kdf derives a key of the given size from a password, in the same way as
+// OpenSSL's EVP_BytesToKey with MD5.
+func kdf(password string, keySize int) []byte {
+	var (
+		key    []byte
+		prev   []byte
+		passwd = []byte(password)
+	)
+
+	for len(key) < keySize {
+		h := md5.New()
+		h.Write(prev)
+		h.Write(passwd)
+		prev = h.Sum(nil)
+		key = append(key, prev...)
+	}
+
+	return key[:keySize]
+}
+
+func (c *aesCrypto) Encrypt(contents []byte) ([]byte, error) {
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher: %w", err)
+	}
+
+	result := make([]byte, aes.BlockSize+len(contents))
+	iv := result[:aes.BlockSize]
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, fmt.Errorf("generate iv: %w", err)
+	}
+
+	stream := cipher.NewCFBEncrypter(block, iv)
+	stream.XORKeyStream(result[aes.BlockSize:], contents)
+
+	return result, nil
+}
+
+func (c *aesCrypto) Decrypt(contents []byte) ([]byte, error) {
+	if len(contents) < aes.BlockSize {
+		return nil, fmt.Errorf("contents too short")
+	}
+
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher: %w", err)
+	}
+
+	iv := contents[:aes.BlockSize]
+	src := contents[aes.BlockSize:]
+	result := make([]byte, len(src))
+
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(result, src)
+
+	return result, nil
+}
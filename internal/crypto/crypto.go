@@ -0,0 +1,34 @@
+// Package crypto provides encryption methods for packets sent between
+// the client and the server.
+package crypto
+
+import (
+	"fmt"
+
+	"ikago/internal/log"
+)
+
+// cryptoLog is the named sub-logger for this package.
+var cryptoLog = log.New("crypto")
+
+// Crypto describes the interface of an encryption method.
+type Crypto interface {
+	// Encrypt encrypts the given contents.
+	Encrypt(contents []byte) ([]byte, error)
+	// Decrypt decrypts the given contents.
+	Decrypt(contents []byte) ([]byte, error)
+}
+
+// Parse returns a Crypto by given method and password.
+func Parse(method, password string) (Crypto, error) {
+	cryptoLog.Tracef("parse method %s\n", method)
+
+	switch method {
+	case "plain", "":
+		return newPlain(), nil
+	case "aes-128-cfb", "aes-192-cfb", "aes-256-cfb":
+		return newAES(method, password)
+	default:
+		return nil, fmt.Errorf("method %s not support", method)
+	}
+}
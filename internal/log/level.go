@@ -0,0 +1,34 @@
+package log
+
+// Level describes the severity of a log entry.
+type Level int
+
+const (
+	// LevelTrace is for very fine-grained, per-packet diagnostics, only
+	// ever gated on by subsystem name via IKAGO_TRACE.
+	LevelTrace Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelTrace:
+		return "trace"
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelFatal:
+		return "fatal"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,100 @@
+package log
+
+import "fmt"
+
+// Logger is a named sub-logger. Each package should obtain its own with
+// New so that entries can be told apart and gated per subsystem.
+type Logger struct {
+	subsystem string
+}
+
+// New returns a Logger for the given subsystem, e.g. "pcap" or "crypto".
+func New(subsystem string) *Logger {
+	return &Logger{subsystem: subsystem}
+}
+
+// std is the unnamed, root logger backing the package-level functions kept
+// for callers that do not need a subsystem of their own.
+var std = New("")
+
+func (l *Logger) log(lv Level, message string) {
+	if lv < currentLevel() {
+		return
+	}
+	write(lv, l.subsystem, message)
+}
+
+// Trace prints a message only if this Logger's subsystem is enabled via
+// IKAGO_TRACE, regardless of the configured level. Callers on a hot path
+// should guard with l.TraceEnabled() to skip formatting entirely when the
+// subsystem is off.
+func (l *Logger) Trace(v ...interface{}) {
+	if !l.TraceEnabled() {
+		return
+	}
+	write(LevelTrace, l.subsystem, fmt.Sprint(v...))
+}
+
+func (l *Logger) Traceln(v ...interface{}) {
+	if !l.TraceEnabled() {
+		return
+	}
+	write(LevelTrace, l.subsystem, fmt.Sprintln(v...))
+}
+
+func (l *Logger) Tracef(format string, v ...interface{}) {
+	if !l.TraceEnabled() {
+		return
+	}
+	write(LevelTrace, l.subsystem, fmt.Sprintf(format, v...))
+}
+
+// TraceEnabled reports whether IKAGO_TRACE gates this Logger's subsystem
+// on, so callers can skip building trace arguments on the hot path.
+func (l *Logger) TraceEnabled() bool {
+	return traceEnabled(l.subsystem)
+}
+
+func (l *Logger) Debug(v ...interface{})   { l.log(LevelDebug, fmt.Sprint(v...)) }
+func (l *Logger) Debugln(v ...interface{}) { l.log(LevelDebug, fmt.Sprintln(v...)) }
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.log(LevelDebug, fmt.Sprintf(format, v...))
+}
+func (l *Logger) Info(v ...interface{})                 { l.log(LevelInfo, fmt.Sprint(v...)) }
+func (l *Logger) Infoln(v ...interface{})               { l.log(LevelInfo, fmt.Sprintln(v...)) }
+func (l *Logger) Infof(format string, v ...interface{}) { l.log(LevelInfo, fmt.Sprintf(format, v...)) }
+func (l *Logger) Warn(v ...interface{})                 { l.log(LevelWarn, fmt.Sprint(v...)) }
+func (l *Logger) Warnln(v ...interface{})               { l.log(LevelWarn, fmt.Sprintln(v...)) }
+func (l *Logger) Warnf(format string, v ...interface{}) { l.log(LevelWarn, fmt.Sprintf(format, v...)) }
+func (l *Logger) Error(v ...interface{})                { l.log(LevelError, fmt.Sprint(v...)) }
+func (l *Logger) Errorln(v ...interface{})              { l.log(LevelError, fmt.Sprintln(v...)) }
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.log(LevelError, fmt.Sprintf(format, v...))
+}
+func (l *Logger) Fatal(v ...interface{})   { l.log(LevelFatal, fmt.Sprint(v...)) }
+func (l *Logger) Fatalln(v ...interface{}) { l.log(LevelFatal, fmt.Sprintln(v...)) }
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.log(LevelFatal, fmt.Sprintf(format, v...))
+}
+
+// Package-level functions proxy to the root logger, kept for existing
+// callers that have no subsystem of their own.
+
+func Trace(v ...interface{})                 { std.Trace(v...) }
+func Traceln(v ...interface{})               { std.Traceln(v...) }
+func Tracef(format string, v ...interface{}) { std.Tracef(format, v...) }
+func Debug(v ...interface{})                 { std.Debug(v...) }
+func Debugln(v ...interface{})               { std.Debugln(v...) }
+func Debugf(format string, v ...interface{}) { std.Debugf(format, v...) }
+func Info(v ...interface{})                  { std.Info(v...) }
+func Infoln(v ...interface{})                { std.Infoln(v...) }
+func Infof(format string, v ...interface{})  { std.Infof(format, v...) }
+func Warn(v ...interface{})                  { std.Warn(v...) }
+func Warnln(v ...interface{})                { std.Warnln(v...) }
+func Warnf(format string, v ...interface{})  { std.Warnf(format, v...) }
+func Error(v ...interface{})                 { std.Error(v...) }
+func Errorln(v ...interface{})               { std.Errorln(v...) }
+func Errorf(format string, v ...interface{}) { std.Errorf(format, v...) }
+func Fatal(v ...interface{})                 { std.Fatal(v...) }
+func Fatalln(v ...interface{})               { std.Fatalln(v...) }
+func Fatalf(format string, v ...interface{}) { std.Fatalf(format, v...) }
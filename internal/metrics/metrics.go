@@ -0,0 +1,97 @@
+// Package metrics exposes Prometheus counters and gauges for the ikago
+// client, plus net/http/pprof handlers, behind a single HTTP server.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds every collector the client reports. The per-filter and
+// drop counters are resolved to a concrete Counter once (at Client.Open and
+// on Reload) and the result is cached by the caller, so the packet hot path
+// never allocates or does a label lookup. UpstreamRTT is looked up by label
+// on every ObserveRTT call, which happens per keepalive reply.
+type Metrics struct {
+	PacketsIn       *prometheus.CounterVec
+	PacketsOut      *prometheus.CounterVec
+	EncryptedBytes  prometheus.Counter
+	Drops           *prometheus.CounterVec
+	UpstreamRTT     *prometheus.GaugeVec
+	EncryptFailures prometheus.Counter
+
+	srv *http.Server
+}
+
+// New creates and registers the client's collectors against reg.
+func New(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
+
+	return &Metrics{
+		PacketsIn: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "ikago_client_packets_in_total",
+			Help: "Packets captured and matched, by filter.",
+		}, []string{"filter"}),
+		PacketsOut: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "ikago_client_packets_out_total",
+			Help: "Packets forwarded to an upstream, by filter.",
+		}, []string{"filter"}),
+		EncryptedBytes: factory.NewCounter(prometheus.CounterOpts{
+			Name: "ikago_client_encrypted_bytes_total",
+			Help: "Bytes written to upstreams after encryption.",
+		}),
+		Drops: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "ikago_client_drops_total",
+			Help: "Packets dropped before being forwarded, by reason.",
+		}, []string{"reason"}),
+		UpstreamRTT: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ikago_client_upstream_rtt_seconds",
+			Help: "Most recent keepalive round-trip time, by upstream.",
+		}, []string{"upstream"}),
+		EncryptFailures: factory.NewCounter(prometheus.CounterOpts{
+			Name: "ikago_client_encrypt_failures_total",
+			Help: "Packets dropped because encrypting them before forwarding failed.",
+		}),
+	}
+}
+
+// ListenAndServe starts serving /metrics and /debug/pprof/* on addr. It
+// blocks until the server stops; call Shutdown from another goroutine to
+// stop it.
+func (m *Metrics) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	m.srv = &http.Server{Addr: addr, Handler: mux}
+
+	err := m.srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown gracefully stops the metrics server started by ListenAndServe.
+func (m *Metrics) Shutdown(ctx context.Context) error {
+	if m.srv == nil {
+		return nil
+	}
+	return m.srv.Shutdown(ctx)
+}
+
+// ObserveRTT is a convenience wrapper recording rtt for an upstream without
+// the caller having to know the metric or label name.
+func (m *Metrics) ObserveRTT(upstream string, rtt time.Duration) {
+	m.UpstreamRTT.WithLabelValues(upstream).Set(rtt.Seconds())
+}
@@ -0,0 +1,52 @@
+package pcap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompileBPF compiles the union of filters into a single libpcap BPF
+// expression, suitable for pcap.Handle.SetBPFFilter, so that non-matching
+// packets are dropped by the kernel instead of being read into userspace
+// and discarded by match.
+func CompileBPF(filters []Filter) string {
+	clauses := make([]string, 0, len(filters))
+	for _, f := range filters {
+		clauses = append(clauses, bpfClause(f))
+	}
+
+	if len(clauses) == 1 {
+		return clauses[0]
+	}
+
+	for i, c := range clauses {
+		clauses[i] = "(" + c + ")"
+	}
+
+	return strings.Join(clauses, " or ")
+}
+
+func bpfClause(f Filter) string {
+	var clause string
+
+	switch ft := f.(type) {
+	case *IPFilter:
+		clause = fmt.Sprintf("host %s", ft.IP)
+	case *PortFilter:
+		clause = fmt.Sprintf("port %d", ft.Port)
+	case *IPPortFilter:
+		clause = fmt.Sprintf("host %s and port %d", ft.IP, ft.Port)
+	case *CIDRFilter:
+		clause = fmt.Sprintf("net %s", ft.IPNet)
+	case *CIDRPortFilter:
+		clause = fmt.Sprintf("net %s and port %d", ft.IPNet, ft.Port)
+	default:
+		return ""
+	}
+
+	if proto := f.Proto(); proto != "" {
+		return fmt.Sprintf("%s and %s", proto, clause)
+	}
+
+	return clause
+}
@@ -0,0 +1,65 @@
+package pcap
+
+import (
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// compile is a test helper asserting that expr is accepted by libpcap's BPF
+// compiler, without needing a live device to install it on.
+func compile(t *testing.T, expr string) {
+	t.Helper()
+
+	if _, err := pcap.NewBPF(layers.LinkTypeEthernet, 65535, expr); err != nil {
+		t.Fatalf("compile %q: %s", expr, err)
+	}
+}
+
+func TestCompileBPF(t *testing.T) {
+	cases := []struct {
+		name    string
+		filters []string
+	}{
+		{"ip", []string{"192.168.1.1"}},
+		{"port", []string{"443"}},
+		{"ip port", []string{"192.168.1.1:443"}},
+		{"cidr", []string{"10.0.0.0/24"}},
+		{"cidr port", []string{"10.0.0.0/24:443"}},
+		{"tcp ip port", []string{"tcp:192.168.1.1:443"}},
+		{"udp cidr port", []string{"udp:10.0.0.0/24:443"}},
+		{"union", []string{"192.168.1.1", "tcp:10.0.0.0/24:443", "53"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			filters := make([]Filter, 0, len(c.filters))
+			for _, s := range c.filters {
+				filter, err := ParseFilter(s)
+				if err != nil {
+					t.Fatalf("parse filter %s: %s", s, err)
+				}
+				filters = append(filters, filter)
+			}
+
+			compile(t, CompileBPF(filters))
+		})
+	}
+}
+
+func TestClientBPFExprExcludesUpPort(t *testing.T) {
+	filter, err := ParseFilter("tcp:10.0.0.0/24:443")
+	if err != nil {
+		t.Fatalf("parse filter: %s", err)
+	}
+
+	p := &Client{Filters: []Filter{filter}, UpPort: 51413}
+
+	expr := p.bpfExpr()
+	compile(t, expr)
+
+	if got, want := expr, "(tcp and net 10.0.0.0/24 and port 443) and not port 51413"; got != want {
+		t.Fatalf("bpfExpr() = %q, want %q", got, want)
+	}
+}
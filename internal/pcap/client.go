@@ -0,0 +1,449 @@
+package pcap
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"github.com/prometheus/client_golang/prometheus"
+	"ikago/internal/config"
+	"ikago/internal/crypto"
+	"ikago/internal/log"
+	"ikago/internal/metrics"
+)
+
+// clientLog is the named sub-logger for Client lifecycle events (open,
+// close, reload); gate it independently from "pcap" via IKAGO_TRACE.
+var clientLog = log.New("client")
+
+// Client describes the packet capture and forwarding client.
+type Client struct {
+	Filters    []Filter
+	UpPort     uint16
+	Upstreams  []*net.UDPAddr
+	Policy     Policy
+	ListenDevs []*Device
+	UpDev      *Device
+	GatewayDev *Device
+	Method     string
+	Password   string
+	Crypto     crypto.Crypto
+	Metrics    *metrics.Metrics
+
+	mu         sync.Mutex
+	handles    map[string]*pcap.Handle
+	conn       *net.UDPConn
+	selector   *selector
+	closed     bool
+	done       chan struct{}
+	probeStop  chan struct{}
+	packetsIn  prometheus.Counter
+	packetsOut prometheus.Counter
+	dropNoUp   prometheus.Counter
+}
+
+// Open starts listening on all listen devices, probing upstreams for
+// liveness, and forwarding matched packets, encrypted, to the upstream
+// selected for their flow. It blocks until Close is called or an
+// unrecoverable error occurs.
+func (p *Client) Open() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return fmt.Errorf("client is closed")
+	}
+
+	if len(p.Upstreams) == 0 {
+		p.mu.Unlock()
+		return fmt.Errorf("no upstream provided")
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: int(p.UpPort)})
+	if err != nil {
+		p.mu.Unlock()
+		return fmt.Errorf("listen upstream port: %w", err)
+	}
+	p.conn = conn
+	p.selector = newSelector(p.Policy, p.Upstreams)
+	p.resolveMetrics()
+
+	bpf := p.bpfExpr()
+	handles := make(map[string]*pcap.Handle, len(p.ListenDevs))
+	for _, dev := range p.ListenDevs {
+		handle, err := openHandle(dev, bpf)
+		if err != nil {
+			for _, h := range handles {
+				h.Close()
+			}
+			conn.Close()
+			p.mu.Unlock()
+			return fmt.Errorf("open device %s: %w", dev.Name, err)
+		}
+
+		handles[dev.Name] = handle
+	}
+	p.handles = handles
+	p.done = make(chan struct{})
+	p.probeStop = make(chan struct{})
+	p.mu.Unlock()
+
+	go p.recvReplies(conn)
+	go p.probe(p.probeStop)
+
+	for _, handle := range handles {
+		p.startCapture(handle)
+	}
+	<-p.done
+
+	return nil
+}
+
+// startCapture launches the packet capture loop for a single handle in its
+// own goroutine.
+func (p *Client) startCapture(handle *pcap.Handle) {
+	go p.capture(handle)
+}
+
+func openHandle(dev *Device, bpf string) (*pcap.Handle, error) {
+	handle, err := pcap.OpenLive(dev.Name, 65535, true, pcap.BlockForever)
+	if err != nil {
+		return nil, err
+	}
+
+	if bpf != "" {
+		if err := handle.SetBPFFilter(bpf); err != nil {
+			handle.Close()
+			return nil, fmt.Errorf("set bpf filter %s: %w", bpf, err)
+		}
+	}
+
+	return handle, nil
+}
+
+// bpfExpr compiles p.Filters into a single BPF expression and excludes
+// p.UpPort so the traffic this Client forwards upstream, and any keepalive
+// replies coming back on the same port, are never recaptured.
+func (p *Client) bpfExpr() string {
+	return p.bpfExprFor(p.Filters)
+}
+
+// bpfExprFor is bpfExpr for a filter set that has not been assigned to
+// p.Filters yet, so Reload can compile the BPF program for its candidate
+// configuration before committing to it.
+func (p *Client) bpfExprFor(filters []Filter) string {
+	exclude := fmt.Sprintf("not port %d", p.UpPort)
+
+	expr := CompileBPF(filters)
+	if expr == "" {
+		return exclude
+	}
+
+	return fmt.Sprintf("(%s) and %s", expr, exclude)
+}
+
+// resolveMetrics resolves every labelled collector this Client uses to a
+// concrete Counter once, so capture's hot path only ever does an Add. The
+// filter label is coarse - the first configured filter stands in for all
+// of them, since match does not report which filter a packet hit.
+func (p *Client) resolveMetrics() {
+	if p.Metrics == nil {
+		p.packetsIn, p.packetsOut, p.dropNoUp = nil, nil, nil
+		return
+	}
+
+	filterLabel := "none"
+	if len(p.Filters) > 0 {
+		filterLabel = p.Filters[0].String()
+	}
+
+	p.packetsIn = p.Metrics.PacketsIn.WithLabelValues(filterLabel)
+	p.packetsOut = p.Metrics.PacketsOut.WithLabelValues(filterLabel)
+	p.dropNoUp = p.Metrics.Drops.WithLabelValues("no-alive-upstream")
+}
+
+// capture reads packets off a single handle, matches them against the
+// configured filters and forwards the matched ones, encrypted, to the
+// upstream selected for their flow.
+func (p *Client) capture(handle *pcap.Handle) {
+	for {
+		data, _, err := handle.ReadPacketData()
+		if err != nil {
+			return
+		}
+
+		if !p.match(data) {
+			continue
+		}
+
+		if p.packetsIn != nil {
+			p.packetsIn.Add(1)
+		}
+
+		p.mu.Lock()
+		conn := p.conn
+		sel := p.selector
+		p.mu.Unlock()
+		if conn == nil || sel == nil {
+			return
+		}
+
+		addr, err := sel.Select(flowOf(data))
+		if err != nil {
+			clientLog.Debugf("Capture: %s\n", err)
+			if p.dropNoUp != nil {
+				p.dropNoUp.Add(1)
+			}
+			continue
+		}
+
+		contents, err := p.Crypto.Encrypt(data)
+		if err != nil {
+			clientLog.Debugf("Capture: encrypt: %s\n", err)
+			if p.Metrics != nil {
+				p.Metrics.EncryptFailures.Inc()
+			}
+			continue
+		}
+
+		if pcapLog.TraceEnabled() {
+			tracePacket("out", flowOf(data), len(data), len(contents))
+		}
+
+		if p.packetsOut != nil {
+			p.packetsOut.Add(1)
+		}
+		if p.Metrics != nil {
+			p.Metrics.EncryptedBytes.Add(float64(len(contents)))
+		}
+
+		_, _ = conn.WriteToUDP(contents, addr)
+	}
+}
+
+// recvReplies reads datagrams coming back from upstreams, handing keepalive
+// replies to the prober and discarding anything else - the response path
+// back into the pcap devices is out of scope here.
+func (p *Client) recvReplies(conn *net.UDPConn) {
+	buf := make([]byte, 65535)
+
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		p.handleProbeReply(buf[:n], addr)
+	}
+}
+
+// match reports whether any filter is configured. It does not inspect the
+// packet: the BPF program installed by bpfExpr does the filtering in the
+// kernel, and a packet delivered by a driver that ignores SetBPFFilter is
+// accepted as well.
+func (p *Client) match(data []byte) bool {
+	return len(p.Filters) > 0
+}
+
+// flowOf derives the FlowKey a captured packet belongs to by parsing its IP
+// and transport headers, so that PolicyHash sticks a connection to the same
+// upstream and spreads distinct flows across upstreams rather than every
+// packet hashing to the same value. The other policies ignore the FlowKey.
+func flowOf(data []byte) FlowKey {
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.NoCopy)
+
+	var flow FlowKey
+
+	switch {
+	case packet.Layer(layers.LayerTypeIPv4) != nil:
+		ip := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+		flow.SrcIP = ip.SrcIP.String()
+		flow.DstIP = ip.DstIP.String()
+		flow.Protocol = uint8(ip.Protocol)
+	case packet.Layer(layers.LayerTypeIPv6) != nil:
+		ip := packet.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+		flow.SrcIP = ip.SrcIP.String()
+		flow.DstIP = ip.DstIP.String()
+		flow.Protocol = uint8(ip.NextHeader)
+	}
+
+	switch {
+	case packet.Layer(layers.LayerTypeTCP) != nil:
+		tcp := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		flow.SrcPort = uint16(tcp.SrcPort)
+		flow.DstPort = uint16(tcp.DstPort)
+	case packet.Layer(layers.LayerTypeUDP) != nil:
+		udp := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+		flow.SrcPort = uint16(udp.SrcPort)
+		flow.DstPort = uint16(udp.DstPort)
+	}
+
+	return flow
+}
+
+// Close closes all listen handles, stops probing and closes the upstream
+// socket.
+func (p *Client) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+
+	for _, handle := range p.handles {
+		handle.Close()
+	}
+	p.handles = nil
+
+	if p.probeStop != nil {
+		close(p.probeStop)
+	}
+
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+
+	if p.done != nil {
+		close(p.done)
+	}
+}
+
+// Reload diff-applies a new configuration on a running Client: listen
+// devices that were added or removed open or close their capture handles,
+// a changed method or password rotates the crypto used for new packets,
+// and a changed set of upstreams or selection policy rebuilds the
+// selector. Devices and handles that are unaffected by the diff are left
+// untouched so that existing flows through them are not disrupted. The new
+// configuration is fully validated before anything is applied, so an
+// invalid configuration leaves the Client exactly as it was. Installing the
+// new BPF program on handles that stay open happens after the rest is
+// applied; a failure there is logged and does not undo the Reload.
+func (p *Client) Reload(cfg *config.Config) error {
+	filters := make([]Filter, 0, len(cfg.Filters))
+	for _, s := range cfg.Filters {
+		filter, err := ParseFilter(s)
+		if err != nil {
+			return fmt.Errorf("parse filter %s: %w", s, err)
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 0 {
+		return fmt.Errorf("no filter provided")
+	}
+
+	c, err := crypto.Parse(cfg.Method, cfg.Password)
+	if err != nil {
+		return fmt.Errorf("parse crypto: %w", err)
+	}
+
+	upstreams := make([]*net.UDPAddr, 0, len(cfg.Servers))
+	for _, s := range cfg.Servers {
+		ipPort, err := ParseIPPort(s)
+		if err != nil {
+			return fmt.Errorf("parse server %s: %w", s, err)
+		}
+		upstreams = append(upstreams, &net.UDPAddr{IP: ipPort.IP, Port: int(ipPort.Port)})
+	}
+	if len(upstreams) == 0 {
+		return fmt.Errorf("no upstream provided")
+	}
+
+	listenDevs, err := FindListenDevs(cfg.ListenDevs)
+	if err != nil {
+		return fmt.Errorf("find listen devices: %w", err)
+	}
+
+	byName := make(map[string]*Device, len(listenDevs))
+	for _, dev := range listenDevs {
+		byName[dev.Name] = dev
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return fmt.Errorf("client is closed")
+	}
+
+	bpf := p.bpfExprFor(filters)
+
+	opened := make(map[string]*pcap.Handle)
+	for name, dev := range byName {
+		if _, ok := p.handles[name]; ok {
+			continue
+		}
+
+		handle, err := openHandle(dev, bpf)
+		if err != nil {
+			for _, h := range opened {
+				h.Close()
+			}
+			return fmt.Errorf("open device %s: %w", name, err)
+		}
+
+		opened[name] = handle
+	}
+
+	if cfg.Verbose != log.Verbose() {
+		clientLog.Infof("Reload: verbose changed to %t\n", cfg.Verbose)
+		log.SetVerbose(cfg.Verbose)
+	}
+
+	for name, handle := range opened {
+		p.handles[name] = handle
+		p.startCapture(handle)
+		clientLog.Infof("Reload: opened device %s\n", name)
+	}
+
+	for name, handle := range p.handles {
+		if _, ok := byName[name]; !ok {
+			handle.Close()
+			delete(p.handles, name)
+			clientLog.Infof("Reload: closed device %s\n", name)
+		}
+	}
+
+	p.ListenDevs = listenDevs
+	p.Filters = filters
+	p.resolveMetrics()
+
+	for name, handle := range p.handles {
+		if err := handle.SetBPFFilter(bpf); err != nil {
+			clientLog.Infof("Reload: set bpf filter on device %s: %s\n", name, err)
+		}
+	}
+
+	policy := Policy(cfg.Policy)
+	if !sameUpstreams(p.Upstreams, upstreams) || policy != p.Policy {
+		p.selector = newSelector(policy, upstreams)
+		p.Upstreams = upstreams
+		p.Policy = policy
+		clientLog.Infof("Reload: upstreams changed to %v (%s)\n", upstreams, policy)
+	}
+
+	if cfg.Method != p.Method || cfg.Password != p.Password {
+		clientLog.Infof("Reload: method changed to %s\n", cfg.Method)
+	}
+	p.Method = cfg.Method
+	p.Password = cfg.Password
+	p.Crypto = c
+
+	return nil
+}
+
+func sameUpstreams(a, b []*net.UDPAddr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].IP.Equal(b[i].IP) || a[i].Port != b[i].Port {
+			return false
+		}
+	}
+	return true
+}
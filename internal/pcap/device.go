@@ -0,0 +1,108 @@
+package pcap
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// Device describes a pcap device with the addresses bound to it.
+type Device struct {
+	Name    string
+	IPAddrs []net.IP
+}
+
+func (d *Device) String() string {
+	if len(d.IPAddrs) == 0 {
+		return d.Name
+	}
+
+	return fmt.Sprintf("%s (%s)", d.Name, d.IPAddrs)
+}
+
+// FindAllDevs returns all valid pcap devices in the current computer.
+func FindAllDevs() ([]*Device, error) {
+	devs, err := pcap.FindAllDevs()
+	if err != nil {
+		return nil, fmt.Errorf("find all devices: %w", err)
+	}
+
+	result := make([]*Device, 0, len(devs))
+
+	for _, dev := range devs {
+		d := &Device{Name: dev.Name}
+
+		for _, addr := range dev.Addresses {
+			d.IPAddrs = append(d.IPAddrs, addr.IP)
+		}
+
+		result = append(result, d)
+	}
+
+	return result, nil
+}
+
+// FindListenDevs returns the pcap devices matching the given names, or all
+// valid devices if no name is given.
+func FindListenDevs(names []string) ([]*Device, error) {
+	devs, err := FindAllDevs()
+	if err != nil {
+		return nil, fmt.Errorf("find all devices: %w", err)
+	}
+
+	if len(names) == 0 {
+		return devs, nil
+	}
+
+	result := make([]*Device, 0, len(names))
+
+	for _, name := range names {
+		var found *Device
+
+		for _, dev := range devs {
+			if dev.Name == name {
+				found = dev
+				break
+			}
+		}
+
+		if found == nil {
+			return nil, fmt.Errorf("device %s not found", name)
+		}
+
+		result = append(result, found)
+	}
+
+	return result, nil
+}
+
+// FindUpstreamDevAndGatewayDev returns the pcap device for routing upstream
+// to, given its name (the first valid device if empty), and the gateway
+// device. The gateway device is always the upstream device itself; no
+// separate gateway lookup is made.
+func FindUpstreamDevAndGatewayDev(name string) (upDev, gatewayDev *Device, err error) {
+	devs, err := FindAllDevs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("find all devices: %w", err)
+	}
+
+	if name != "" {
+		for _, dev := range devs {
+			if dev.Name == name {
+				upDev = dev
+				break
+			}
+		}
+
+		if upDev == nil {
+			return nil, nil, fmt.Errorf("device %s not found", name)
+		}
+	} else if len(devs) > 0 {
+		upDev = devs[0]
+	}
+
+	gatewayDev = upDev
+
+	return upDev, gatewayDev, nil
+}
@@ -0,0 +1,237 @@
+package pcap
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"ikago/internal/log"
+)
+
+// filterLog is the named sub-logger for filter parsing and matching.
+var filterLog = log.New("filter")
+
+// FilterType describes the type of a Filter.
+type FilterType int
+
+const (
+	// FilterTypeIP describes a Filter filtering by an IP address.
+	FilterTypeIP FilterType = iota
+	// FilterTypePort describes a Filter filtering by a port.
+	FilterTypePort
+	// FilterTypeIPPort describes a Filter filtering by an IP address and a
+	// port.
+	FilterTypeIPPort
+	// FilterTypeCIDR describes a Filter filtering by a CIDR block.
+	FilterTypeCIDR
+	// FilterTypeCIDRPort describes a Filter filtering by a CIDR block and a
+	// port.
+	FilterTypeCIDRPort
+)
+
+// Filter describes the interface of a filter used to select packets to
+// capture.
+type Filter interface {
+	fmt.Stringer
+
+	// FilterType returns the type of the Filter.
+	FilterType() FilterType
+
+	// Proto returns the protocol qualifier the Filter was parsed with, "tcp"
+	// or "udp", or "" if the Filter applies to any protocol.
+	Proto() string
+}
+
+// IPFilter is a Filter filtering by an IP address.
+type IPFilter struct {
+	IP        net.IP
+	ProtoName string
+}
+
+func (f *IPFilter) FilterType() FilterType {
+	return FilterTypeIP
+}
+
+func (f *IPFilter) Proto() string {
+	return f.ProtoName
+}
+
+func (f *IPFilter) String() string {
+	return withProto(f.ProtoName, f.IP.String())
+}
+
+// PortFilter is a Filter filtering by a port.
+type PortFilter struct {
+	Port      uint16
+	ProtoName string
+}
+
+func (f *PortFilter) FilterType() FilterType {
+	return FilterTypePort
+}
+
+func (f *PortFilter) Proto() string {
+	return f.ProtoName
+}
+
+func (f *PortFilter) String() string {
+	return withProto(f.ProtoName, fmt.Sprintf(":%d", f.Port))
+}
+
+// IPPortFilter is a Filter filtering by an IP address and a port.
+type IPPortFilter struct {
+	IP        net.IP
+	Port      uint16
+	ProtoName string
+}
+
+func (f *IPPortFilter) FilterType() FilterType {
+	return FilterTypeIPPort
+}
+
+func (f *IPPortFilter) Proto() string {
+	return f.ProtoName
+}
+
+func (f *IPPortFilter) String() string {
+	return withProto(f.ProtoName, fmt.Sprintf("%s:%d", f.IP, f.Port))
+}
+
+// CIDRFilter is a Filter filtering by a CIDR block.
+type CIDRFilter struct {
+	IPNet     *net.IPNet
+	ProtoName string
+}
+
+func (f *CIDRFilter) FilterType() FilterType {
+	return FilterTypeCIDR
+}
+
+func (f *CIDRFilter) Proto() string {
+	return f.ProtoName
+}
+
+func (f *CIDRFilter) String() string {
+	return withProto(f.ProtoName, f.IPNet.String())
+}
+
+// CIDRPortFilter is a Filter filtering by a CIDR block and a port.
+type CIDRPortFilter struct {
+	IPNet     *net.IPNet
+	Port      uint16
+	ProtoName string
+}
+
+func (f *CIDRPortFilter) FilterType() FilterType {
+	return FilterTypeCIDRPort
+}
+
+func (f *CIDRPortFilter) Proto() string {
+	return f.ProtoName
+}
+
+func (f *CIDRPortFilter) String() string {
+	return withProto(f.ProtoName, fmt.Sprintf("%s:%d", f.IPNet, f.Port))
+}
+
+func withProto(proto, s string) string {
+	if proto == "" {
+		return s
+	}
+	return proto + ":" + s
+}
+
+// ParseFilter parses a Filter from a string in the form of "ip", "port",
+// "ip:port", "cidr" or "cidr:port", e.g. "10.0.0.0/24:443". Any of these may
+// be prefixed with a "tcp:" or "udp:" protocol qualifier, e.g.
+// "tcp:10.0.0.0/24:443".
+func ParseFilter(s string) (Filter, error) {
+	filterLog.Tracef("parse filter %s\n", s)
+
+	proto := ""
+	switch {
+	case strings.HasPrefix(s, "tcp:"):
+		proto, s = "tcp", s[len("tcp:"):]
+	case strings.HasPrefix(s, "udp:"):
+		proto, s = "udp", s[len("udp:"):]
+	}
+
+	if !strings.Contains(s, ":") {
+		if port, err := strconv.ParseUint(s, 10, 16); err == nil {
+			return &PortFilter{Port: uint16(port), ProtoName: proto}, nil
+		}
+
+		if strings.Contains(s, "/") {
+			_, ipNet, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, fmt.Errorf("parse cidr %s: %w", s, err)
+			}
+
+			return &CIDRFilter{IPNet: ipNet, ProtoName: proto}, nil
+		}
+
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip %s", s)
+		}
+
+		return &IPFilter{IP: ip, ProtoName: proto}, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, fmt.Errorf("split host port: %w", err)
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("parse port %s: %w", portStr, err)
+	}
+
+	if strings.Contains(host, "/") {
+		_, ipNet, err := net.ParseCIDR(host)
+		if err != nil {
+			return nil, fmt.Errorf("parse cidr %s: %w", host, err)
+		}
+
+		return &CIDRPortFilter{IPNet: ipNet, Port: uint16(port), ProtoName: proto}, nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip %s", host)
+	}
+
+	return &IPPortFilter{IP: ip, Port: uint16(port), ProtoName: proto}, nil
+}
+
+// IPPort describes an IP address and a port.
+type IPPort struct {
+	IP   net.IP
+	Port uint16
+}
+
+func (p IPPort) String() string {
+	return fmt.Sprintf("%s:%d", p.IP, p.Port)
+}
+
+// ParseIPPort parses an IPPort from a string in the form of "ip:port".
+func ParseIPPort(s string) (*IPPort, error) {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, fmt.Errorf("split host port: %w", err)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip %s", host)
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("parse port %s: %w", portStr, err)
+	}
+
+	return &IPPort{IP: ip, Port: uint16(port)}, nil
+}
@@ -0,0 +1,65 @@
+package pcap
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestFlowOfParsesIPv4UDP(t *testing.T) {
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IPv4(192, 168, 1, 2),
+		DstIP:    net.IPv4(10, 0, 0, 1),
+	}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 53}
+	if err := udp.SetNetworkLayerForChecksum(ip); err != nil {
+		t.Fatalf("set network layer: %s", err)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, udp, gopacket.Payload("x")); err != nil {
+		t.Fatalf("serialize: %s", err)
+	}
+
+	flow := flowOf(buf.Bytes())
+
+	want := FlowKey{
+		SrcIP:    "192.168.1.2",
+		DstIP:    "10.0.0.1",
+		SrcPort:  1234,
+		DstPort:  53,
+		Protocol: uint8(layers.IPProtocolUDP),
+	}
+	if flow != want {
+		t.Fatalf("flowOf() = %+v, want %+v", flow, want)
+	}
+}
+
+func TestFlowOfNonIPReturnsZeroValue(t *testing.T) {
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeARP,
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, gopacket.Payload("x")); err != nil {
+		t.Fatalf("serialize: %s", err)
+	}
+
+	if flow := flowOf(buf.Bytes()); flow != (FlowKey{}) {
+		t.Fatalf("flowOf() for a non-IP packet = %+v, want zero value", flow)
+	}
+}
@@ -0,0 +1,125 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+const (
+	// probeInterval is the time between two keepalive probes sent to each
+	// upstream.
+	probeInterval = 5 * time.Second
+	// probeTimeout is the time a probe is given to be acknowledged before
+	// the upstream is considered dead.
+	probeTimeout = 2 * time.Second
+	// probeMagic prefixes every keepalive packet so it can be told apart
+	// from proxied traffic on the wire.
+	probeMagic = "IKAGOPING"
+)
+
+// probe runs until stop is closed, periodically sending an encrypted
+// keepalive packet (magic + sequence number) to every upstream and
+// demoting or re-promoting it in the selector depending on whether it
+// answers within probeTimeout.
+func (p *Client) probe(stop <-chan struct{}) {
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	var seq uint32
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			seq++
+			p.probeOnce(seq)
+		}
+	}
+}
+
+func (p *Client) probeOnce(seq uint32) {
+	p.mu.Lock()
+	conn := p.conn
+	sel := p.selector
+	p.mu.Unlock()
+
+	if conn == nil || sel == nil {
+		return
+	}
+
+	packet := make([]byte, len(probeMagic)+4)
+	copy(packet, probeMagic)
+	binary.BigEndian.PutUint32(packet[len(probeMagic):], seq)
+
+	contents, err := p.Crypto.Encrypt(packet)
+	if err != nil {
+		return
+	}
+
+	for _, u := range sel.upstreams {
+		u.markProbeSent(seq, time.Now())
+
+		if _, err := conn.WriteToUDP(contents, u.addr); err != nil {
+			u.setAlive(false, 0)
+			clientLog.Infof("Probe: upstream %s did not respond, demoted\n", u.addr)
+			continue
+		}
+
+		// Give the upstream probeTimeout to reply; handleProbeReply
+		// confirms the probe if it does, so only demote here if it is
+		// still the outstanding one once the timer fires.
+		time.AfterFunc(probeTimeout, func(u *upstream, seq uint32) func() {
+			return func() {
+				if u.expireProbe(seq) {
+					clientLog.Infof("Probe: upstream %s did not respond, demoted\n", u.addr)
+				}
+			}
+		}(u, seq))
+	}
+}
+
+// handleProbeReply reports whether data is a keepalive reply from addr, and
+// if so, re-promotes that upstream and records its RTT. data arrives
+// encrypted, the same way probeOnce sent it, so it is decrypted before the
+// magic is checked.
+func (p *Client) handleProbeReply(data []byte, addr *net.UDPAddr) bool {
+	plain, err := p.Crypto.Decrypt(data)
+	if err != nil {
+		return false
+	}
+
+	if len(plain) < len(probeMagic)+4 || string(plain[:len(probeMagic)]) != probeMagic {
+		return false
+	}
+	seq := binary.BigEndian.Uint32(plain[len(probeMagic):])
+
+	p.mu.Lock()
+	sel := p.selector
+	p.mu.Unlock()
+	if sel == nil {
+		return true
+	}
+
+	for _, u := range sel.upstreams {
+		if !u.addr.IP.Equal(addr.IP) || u.addr.Port != addr.Port {
+			continue
+		}
+
+		rtt, wasDead, ok := u.confirmProbe(seq)
+		if !ok {
+			break
+		}
+
+		if wasDead {
+			clientLog.Infof("Probe: upstream %s re-promoted\n", u.addr)
+		}
+		if p.Metrics != nil {
+			p.Metrics.ObserveRTT(u.addr.String(), rtt)
+		}
+		break
+	}
+
+	return true
+}
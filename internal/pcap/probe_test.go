@@ -0,0 +1,90 @@
+package pcap
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func testUpstream() *upstream {
+	return newUpstream(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 51413})
+}
+
+func TestUpstreamExpireProbeDemotesOnTimeout(t *testing.T) {
+	u := testUpstream()
+
+	u.markProbeSent(1, time.Now())
+	if !u.isAlive() {
+		t.Fatalf("upstream should still be alive while a probe is outstanding")
+	}
+
+	if !u.expireProbe(1) {
+		t.Fatalf("expireProbe(1) = false, want true for an outstanding probe")
+	}
+	if u.isAlive() {
+		t.Fatalf("upstream should be demoted after its probe times out")
+	}
+}
+
+func TestUpstreamConfirmProbeCancelsTheTimeout(t *testing.T) {
+	u := testUpstream()
+
+	u.markProbeSent(1, time.Now())
+
+	rtt, wasDead, ok := u.confirmProbe(1)
+	if !ok {
+		t.Fatalf("confirmProbe(1) = false, want true for the outstanding probe")
+	}
+	if wasDead {
+		t.Fatalf("confirmProbe should report wasDead=false for an upstream that never demoted")
+	}
+	if rtt < 0 {
+		t.Fatalf("confirmProbe returned negative rtt: %s", rtt)
+	}
+	if !u.isAlive() {
+		t.Fatalf("upstream should be alive after a confirmed probe")
+	}
+
+	// The reply already arrived, so a late timer firing for the same seq
+	// must not re-demote it.
+	if u.expireProbe(1) {
+		t.Fatalf("expireProbe(1) after confirmProbe(1) = true, want false")
+	}
+	if !u.isAlive() {
+		t.Fatalf("upstream demoted by a stale timeout after it was already confirmed")
+	}
+}
+
+func TestUpstreamConfirmProbeReportsRepromotion(t *testing.T) {
+	u := testUpstream()
+
+	u.markProbeSent(1, time.Now())
+	u.expireProbe(1)
+	if u.isAlive() {
+		t.Fatalf("setup: upstream should be demoted")
+	}
+
+	u.markProbeSent(2, time.Now())
+	_, wasDead, ok := u.confirmProbe(2)
+	if !ok {
+		t.Fatalf("confirmProbe(2) = false, want true")
+	}
+	if !wasDead {
+		t.Fatalf("confirmProbe should report wasDead=true for a re-promoted upstream")
+	}
+	if !u.isAlive() {
+		t.Fatalf("upstream should be alive after a confirmed probe")
+	}
+}
+
+func TestUpstreamConfirmProbeIgnoresMismatchedSeq(t *testing.T) {
+	u := testUpstream()
+
+	u.markProbeSent(2, time.Now())
+	if _, _, ok := u.confirmProbe(1); ok {
+		t.Fatalf("confirmProbe(1) with pending seq 2: want false, got true")
+	}
+	if !u.isAlive() {
+		t.Fatalf("a mismatched reply must not change liveness")
+	}
+}
@@ -0,0 +1,138 @@
+package pcap
+
+import (
+	"net"
+	"testing"
+)
+
+func testUpstreams(n int) []*net.UDPAddr {
+	addrs := make([]*net.UDPAddr, 0, n)
+	for i := 0; i < n; i++ {
+		addrs = append(addrs, &net.UDPAddr{IP: net.IPv4(10, 0, 0, byte(i+1)), Port: 51413})
+	}
+	return addrs
+}
+
+func TestSelectorRoundRobinCycles(t *testing.T) {
+	sel := newSelector(PolicyRoundRobin, testUpstreams(3))
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		addr, err := sel.Select(FlowKey{})
+		if err != nil {
+			t.Fatalf("Select: %s", err)
+		}
+		got = append(got, addr.String())
+	}
+
+	if got[0] == got[1] || got[1] == got[2] {
+		t.Fatalf("round-robin did not cycle: %v", got)
+	}
+	if got[0] != got[3] || got[1] != got[4] || got[2] != got[5] {
+		t.Fatalf("round-robin did not repeat after a full cycle: %v", got)
+	}
+}
+
+func TestSelectorFailoverAlwaysPrefersFirstAlive(t *testing.T) {
+	addrs := testUpstreams(3)
+	sel := newSelector(PolicyFailover, addrs)
+
+	addr, err := sel.Select(FlowKey{})
+	if err != nil {
+		t.Fatalf("Select: %s", err)
+	}
+	if addr.String() != addrs[0].String() {
+		t.Fatalf("Select() = %s, want first upstream %s", addr, addrs[0])
+	}
+
+	sel.upstreams[0].setAlive(false, 0)
+
+	addr, err = sel.Select(FlowKey{})
+	if err != nil {
+		t.Fatalf("Select: %s", err)
+	}
+	if addr.String() != addrs[1].String() {
+		t.Fatalf("Select() after demoting first = %s, want %s", addr, addrs[1])
+	}
+}
+
+func TestSelectorHashIsStickyPerFlowAndDistributesAcrossFlows(t *testing.T) {
+	sel := newSelector(PolicyHash, testUpstreams(4))
+
+	flows := []FlowKey{
+		{SrcIP: "192.168.1.2", DstIP: "10.0.0.1", SrcPort: 1234, DstPort: 443, Protocol: 6},
+		{SrcIP: "192.168.1.3", DstIP: "10.0.0.1", SrcPort: 5678, DstPort: 443, Protocol: 6},
+		{SrcIP: "192.168.1.4", DstIP: "10.0.0.1", SrcPort: 9012, DstPort: 443, Protocol: 6},
+	}
+
+	seen := make(map[string]bool)
+	for _, flow := range flows {
+		first, err := sel.Select(flow)
+		if err != nil {
+			t.Fatalf("Select: %s", err)
+		}
+
+		for i := 0; i < 5; i++ {
+			addr, err := sel.Select(flow)
+			if err != nil {
+				t.Fatalf("Select: %s", err)
+			}
+			if addr.String() != first.String() {
+				t.Fatalf("hash policy is not sticky for flow %+v: got %s and %s", flow, first, addr)
+			}
+		}
+
+		seen[first.String()] = true
+	}
+
+	if len(seen) < 2 {
+		t.Fatalf("hash policy did not distribute distinct flows across upstreams: %v", seen)
+	}
+}
+
+func TestSelectorRandomVariesAcrossFlows(t *testing.T) {
+	sel := newSelector(PolicyRandom, testUpstreams(8))
+
+	seen := make(map[string]bool)
+	for i := 0; i < 8; i++ {
+		flow := FlowKey{SrcIP: "10.1.2.3", DstIP: "10.0.0.1", SrcPort: uint16(1000 + i), DstPort: 443, Protocol: 6}
+		addr, err := sel.Select(flow)
+		if err != nil {
+			t.Fatalf("Select: %s", err)
+		}
+		seen[addr.String()] = true
+	}
+
+	if len(seen) < 2 {
+		t.Fatalf("random policy picked the same upstream for every flow: %v", seen)
+	}
+}
+
+func TestSelectorRandomVariesWithinTheSameFlow(t *testing.T) {
+	sel := newSelector(PolicyRandom, testUpstreams(8))
+	flow := FlowKey{SrcIP: "10.1.2.3", DstIP: "10.0.0.1", SrcPort: 1234, DstPort: 443, Protocol: 6}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 30; i++ {
+		addr, err := sel.Select(flow)
+		if err != nil {
+			t.Fatalf("Select: %s", err)
+		}
+		seen[addr.String()] = true
+	}
+
+	if len(seen) < 2 {
+		t.Fatalf("random policy picked the same upstream for every call on one flow: %v", seen)
+	}
+}
+
+func TestSelectorNoAliveUpstreamErrors(t *testing.T) {
+	sel := newSelector(PolicyRoundRobin, testUpstreams(2))
+	for _, u := range sel.upstreams {
+		u.setAlive(false, 0)
+	}
+
+	if _, err := sel.Select(FlowKey{}); err == nil {
+		t.Fatalf("Select() with no alive upstream: want error, got nil")
+	}
+}
@@ -0,0 +1,22 @@
+package pcap
+
+import "ikago/internal/log"
+
+// pcapLog is the named sub-logger for this package's general diagnostics;
+// filter.go and client.go have their own "filter" and "client" loggers for
+// finer-grained IKAGO_TRACE gating.
+var pcapLog = log.New("pcap")
+
+// tracePacket logs a single captured packet's 5-tuple, direction, length
+// and encrypted size. The subsystem check is done first so that building
+// the log line is skipped unless IKAGO_TRACE=pcap (or *) is set; the
+// FlowKey is built by the caller, which guards the call with
+// pcapLog.TraceEnabled() to skip that too.
+func tracePacket(direction string, flow FlowKey, length, encryptedLength int) {
+	if !pcapLog.TraceEnabled() {
+		return
+	}
+
+	pcapLog.Tracef("%s %s:%d -> %s:%d len=%d enc=%d\n",
+		direction, flow.SrcIP, flow.SrcPort, flow.DstIP, flow.DstPort, length, encryptedLength)
+}
@@ -0,0 +1,178 @@
+package pcap
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// Policy describes how a Client picks an upstream for a given flow.
+type Policy string
+
+const (
+	// PolicyRoundRobin cycles through the alive upstreams in order.
+	PolicyRoundRobin Policy = "round-robin"
+	// PolicyRandom picks a random alive upstream on every Select call, so
+	// packets of the same flow may go to different upstreams.
+	PolicyRandom Policy = "random"
+	// PolicyHash sticks a flow to an alive upstream by hashing its 5-tuple,
+	// so packets of the same connection consistently hit the same server.
+	PolicyHash Policy = "hash"
+	// PolicyFailover always prefers the first alive upstream, falling back
+	// to the next ones in order.
+	PolicyFailover Policy = "failover"
+)
+
+// FlowKey identifies a single flow by its 5-tuple.
+type FlowKey struct {
+	SrcIP    string
+	DstIP    string
+	SrcPort  uint16
+	DstPort  uint16
+	Protocol uint8
+}
+
+// upstream tracks the liveness of a single upstream server.
+type upstream struct {
+	addr *net.UDPAddr
+
+	mu            sync.RWMutex
+	alive         bool
+	rtt           time.Duration
+	probePending  bool
+	pendingSeq    uint32
+	pendingSentAt time.Time
+}
+
+func newUpstream(addr *net.UDPAddr) *upstream {
+	// Upstreams start out alive; the prober demotes them if probes fail.
+	return &upstream{addr: addr, alive: true}
+}
+
+func (u *upstream) isAlive() bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.alive
+}
+
+func (u *upstream) setAlive(alive bool, rtt time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.alive = alive
+	if alive {
+		u.rtt = rtt
+	}
+}
+
+// markProbeSent records that a keepalive probe with the given sequence
+// number was just sent to u, so a later confirmProbe or expireProbe for the
+// same seq can be told apart from a probe that has since been superseded.
+func (u *upstream) markProbeSent(seq uint32, sentAt time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.probePending = true
+	u.pendingSeq = seq
+	u.pendingSentAt = sentAt
+}
+
+// confirmProbe reports whether seq is the outstanding probe on u, and if so
+// clears the pending state, marks u alive and records the round-trip time.
+// wasDead reports whether u was demoted before this reply arrived.
+func (u *upstream) confirmProbe(seq uint32) (rtt time.Duration, wasDead bool, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if !u.probePending || u.pendingSeq != seq {
+		return 0, false, false
+	}
+
+	u.probePending = false
+	wasDead = !u.alive
+	u.alive = true
+	u.rtt = time.Since(u.pendingSentAt)
+
+	return u.rtt, wasDead, true
+}
+
+// expireProbe demotes u if seq is still the outstanding probe, i.e. no
+// reply arrived within probeTimeout. It reports whether it demoted u, so
+// the caller only logs once per timeout.
+func (u *upstream) expireProbe(seq uint32) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if !u.probePending || u.pendingSeq != seq {
+		return false
+	}
+
+	u.probePending = false
+	u.alive = false
+
+	return true
+}
+
+// selector picks an upstream among a fixed, ordered list of candidates.
+type selector struct {
+	mu        sync.Mutex
+	policy    Policy
+	upstreams []*upstream
+	next      int
+}
+
+func newSelector(policy Policy, addrs []*net.UDPAddr) *selector {
+	upstreams := make([]*upstream, 0, len(addrs))
+	for _, addr := range addrs {
+		upstreams = append(upstreams, newUpstream(addr))
+	}
+
+	return &selector{policy: policy, upstreams: upstreams}
+}
+
+func (s *selector) alive() []*upstream {
+	alive := make([]*upstream, 0, len(s.upstreams))
+	for _, u := range s.upstreams {
+		if u.isAlive() {
+			alive = append(alive, u)
+		}
+	}
+	return alive
+}
+
+// Select returns the upstream to use for the given flow, according to the
+// selector's policy. It only considers upstreams currently marked alive.
+func (s *selector) Select(flow FlowKey) (*net.UDPAddr, error) {
+	alive := s.alive()
+	if len(alive) == 0 {
+		return nil, fmt.Errorf("no alive upstream")
+	}
+
+	switch s.policy {
+	case PolicyRandom:
+		return alive[rand.Intn(len(alive))].addr, nil
+	case PolicyHash:
+		return alive[hashFlow(flow)%uint32(len(alive))].addr, nil
+	case PolicyFailover:
+		return alive[0].addr, nil
+	case PolicyRoundRobin, "":
+		fallthrough
+	default:
+		s.mu.Lock()
+		u := alive[s.next%len(alive)]
+		s.next++
+		s.mu.Unlock()
+		return u.addr, nil
+	}
+}
+
+func hashFlow(flow FlowKey) uint32 {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d-%s:%d/%d", flow.SrcIP, flow.SrcPort, flow.DstIP, flow.DstPort, flow.Protocol)
+	return h.Sum32()
+}